@@ -0,0 +1,485 @@
+// Authenticated random-access format.
+
+// NewCTRReaderAt gives confidentiality but no integrity: a single flipped
+// ciphertext byte silently corrupts the plaintext. This file layers a
+// chunked, Merkle-authenticated format on top of CTR so random access stays
+// cheap while tampering is detected.
+//
+// On-disk format:
+//
+//	[header][chunk 0 ciphertext][chunk 0 tag]...[chunk N-1 ciphertext][chunk N-1 tag][merkle trailer]
+//
+// Plaintext is split into fixed-size chunks (the last may be shorter). Each
+// chunk is encrypted with CTR using the IV advanced by chunkIndex*blocksPerChunk
+// (see AddCounter), so chunks never reuse a counter value, and is
+// authenticated with an HMAC-SHA256 tag over chunkIndex||ciphertext, stored
+// as a trailing footer; these per-chunk tags are the leaves of a binary
+// Merkle tree. The trailer holds that tree's internal levels (the leaves are
+// already on disk as chunk footers, and the root lives in the header), so
+// authenticating any one chunk costs reading that chunk plus O(log N)
+// sibling hashes along its path to the root, never the tags off that path.
+// The header records the chunk size, total plaintext length, the base IV
+// and the root, and is itself followed by an HMAC-SHA256 tag over those
+// fields, so an attacker without macKey can't forge a different
+// IV/length/root or truncate trailing chunks by rewriting the header.
+package aesctr
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	authMagic             = "GAC1"
+	authMacAlgoHMACSHA256 = 1
+	authTagSize           = sha256.Size
+	// magic(4) + chunkSize(4) + totalLen(8) + macAlgo(1) + iv(16) + root(32)
+	authHeaderFieldsSize = 4 + 4 + 8 + 1 + 16 + 32
+	// authHeaderFieldsSize plus a trailing HMAC-SHA256 tag over those fields,
+	// so the header itself (and, transitively, the Merkle root it carries)
+	// can't be forged or truncated without macKey.
+	authHeaderSize = authHeaderFieldsSize + authTagSize
+)
+
+// ErrAuthentication is returned when a chunk's recomputed tag, hashed up its
+// sibling path, does not reproduce the Merkle root recorded in the header.
+var ErrAuthentication = errors.New("aesctr: chunk failed authentication")
+
+type authHeader struct {
+	chunkSize int
+	totalLen  int64
+	iv        []byte
+	root      []byte
+}
+
+// writeAuthHeader writes h followed by an HMAC-SHA256 tag over its fields
+// under macKey, so the header (IV, chunk size, total length and Merkle
+// root) can't be tampered with or truncated without detection.
+func writeAuthHeader(w io.Writer, h *authHeader, macKey []byte) error {
+	buf := make([]byte, authHeaderSize)
+	copy(buf[0:4], authMagic)
+	binary.BigEndian.PutUint32(buf[4:8], uint32(h.chunkSize))
+	binary.BigEndian.PutUint64(buf[8:16], uint64(h.totalLen))
+	buf[16] = authMacAlgoHMACSHA256
+	copy(buf[17:33], h.iv)
+	copy(buf[33:65], h.root)
+
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(buf[:authHeaderFieldsSize])
+	copy(buf[authHeaderFieldsSize:], mac.Sum(nil))
+
+	_, err := w.Write(buf)
+	return err
+}
+
+func readAuthHeader(r io.ReaderAt, macKey []byte) (*authHeader, error) {
+	buf := make([]byte, authHeaderSize)
+	if _, err := r.ReadAt(buf, 0); err != nil {
+		return nil, fmt.Errorf("aesctr: reading header: %w", err)
+	}
+	if string(buf[0:4]) != authMagic {
+		return nil, errors.New("aesctr: not an authenticated aesctr file")
+	}
+	if buf[16] != authMacAlgoHMACSHA256 {
+		return nil, errors.New("aesctr: unsupported MAC algorithm")
+	}
+
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(buf[:authHeaderFieldsSize])
+	if !hmac.Equal(mac.Sum(nil), buf[authHeaderFieldsSize:authHeaderSize]) {
+		return nil, errors.New("aesctr: header failed authentication")
+	}
+
+	return &authHeader{
+		chunkSize: int(binary.BigEndian.Uint32(buf[4:8])),
+		totalLen:  int64(binary.BigEndian.Uint64(buf[8:16])),
+		iv:        dup(buf[17:33]),
+		root:      dup(buf[33:65]),
+	}, nil
+}
+
+// merkleLevels builds every level of a binary Merkle tree over tags,
+// duplicating the last node at each level when the level has odd length.
+// levels[0] is tags itself and levels[len(levels)-1] is the single-node root
+// level; everything in between is the internal levels that get written to
+// the trailer so a chunk can be authenticated by climbing only its sibling
+// path, without reloading every leaf (see AuthenticatedReaderAt.verifyChunk).
+func merkleLevels(tags [][]byte) [][][]byte {
+	if len(tags) == 0 {
+		sum := sha256.Sum256(nil)
+		return [][][]byte{{sum[:]}}
+	}
+
+	levels := [][][]byte{tags}
+	level := tags
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			h := sha256.New()
+			h.Write(level[i])
+			if i+1 < len(level) {
+				h.Write(level[i+1])
+			} else {
+				h.Write(level[i])
+			}
+			next = append(next, h.Sum(nil))
+		}
+		levels = append(levels, next)
+		level = next
+	}
+	return levels
+}
+
+func merkleRoot(tags [][]byte) []byte {
+	levels := merkleLevels(tags)
+	return levels[len(levels)-1][0]
+}
+
+// merkleLevelSizes returns the node count of every level of the Merkle tree
+// over n leaves, from the leaves (index 0) up to the root (the last, size 1
+// for n > 0). For n > 0 it mirrors the level structure merkleLevels builds,
+// but needs none of the actual tags, so a reader can precompute it from the
+// header's chunk count alone; for n == 0 it returns a single level of size 0.
+func merkleLevelSizes(n int64) []int64 {
+	if n == 0 {
+		return []int64{0}
+	}
+	sizes := []int64{n}
+	for sizes[len(sizes)-1] > 1 {
+		sizes = append(sizes, (sizes[len(sizes)-1]+1)/2)
+	}
+	return sizes
+}
+
+func chunkTag(macKey []byte, chunkIndex int64, ciphertext []byte) []byte {
+	mac := hmac.New(sha256.New, macKey)
+	var idxBuf [8]byte
+	binary.BigEndian.PutUint64(idxBuf[:], uint64(chunkIndex))
+	mac.Write(idxBuf[:])
+	mac.Write(ciphertext)
+	return mac.Sum(nil)
+}
+
+// AuthenticatedReaderAt is a ReaderAt over the authenticated chunked format
+// produced by AuthenticatedWriter. It is safe for concurrent use: its fields
+// are fixed at construction, and ReadAt only allocates call-local buffers.
+type AuthenticatedReaderAt struct {
+	r      io.ReaderAt
+	block  cipher.Block
+	macKey []byte
+
+	h              *authHeader
+	blocksPerChunk int64
+	numChunks      int64
+	dataOffset     int64
+
+	// levelSizes[l] is the node count of Merkle level l (0 = leaves, last =
+	// root). trailerLevelOffset[l], for 1 <= l < len(levelSizes)-1, is the
+	// file offset of level l's nodes in the trailer written by
+	// AuthenticatedWriter.Close; level 0 has no trailer entry because its
+	// nodes are the chunk footer tags already stored next to each chunk.
+	levelSizes         []int64
+	trailerLevelOffset []int64
+}
+
+// NewAuthenticatedReaderAt opens an authenticated chunked aesctr file. It
+// reads and authenticates the header, which is all construction needs: the
+// Merkle level sizes and trailer layout follow from totalLen and chunkSize
+// alone. Each chunk is authenticated lazily by ReadAt, which walks that
+// chunk's O(log N) sibling path up to the header's root instead of trusting
+// a cached tag table.
+func NewAuthenticatedReaderAt(reader io.ReaderAt, block cipher.Block, macKey []byte) (*AuthenticatedReaderAt, error) {
+	h, err := readAuthHeader(reader, macKey)
+	if err != nil {
+		return nil, err
+	}
+	if h.chunkSize <= 0 || h.chunkSize%block.BlockSize() != 0 {
+		return nil, errors.New("aesctr: chunk size must be a positive multiple of the block size")
+	}
+
+	var numChunks int64
+	if h.totalLen > 0 {
+		numChunks = (h.totalLen + int64(h.chunkSize) - 1) / int64(h.chunkSize)
+	}
+
+	a := &AuthenticatedReaderAt{
+		r:              reader,
+		block:          block,
+		macKey:         dup(macKey),
+		h:              h,
+		blocksPerChunk: int64(h.chunkSize) / int64(block.BlockSize()),
+		numChunks:      numChunks,
+		dataOffset:     int64(authHeaderSize),
+	}
+
+	if numChunks > 0 {
+		a.levelSizes = merkleLevelSizes(numChunks)
+		trailerOffset := a.dataOffset + dataSectionSize(h.totalLen, h.chunkSize)
+		a.trailerLevelOffset = make([]int64, len(a.levelSizes))
+		offset := trailerOffset
+		for l := 1; l < len(a.levelSizes)-1; l++ {
+			a.trailerLevelOffset[l] = offset
+			offset += a.levelSizes[l] * int64(authTagSize)
+		}
+	}
+
+	return a, nil
+}
+
+func (a *AuthenticatedReaderAt) chunkPlainLen(i int64) int64 {
+	if i == a.numChunks-1 {
+		return a.h.totalLen - i*int64(a.h.chunkSize)
+	}
+	return int64(a.h.chunkSize)
+}
+
+func (a *AuthenticatedReaderAt) chunkOffset(i int64) int64 {
+	return a.dataOffset + i*(int64(a.h.chunkSize)+int64(authTagSize))
+}
+
+// dataSectionSize returns the number of bytes the chunk ciphertext+tag
+// section occupies on disk for a file of totalLen bytes split into
+// chunkSize-sized chunks, i.e. the offset of the Merkle trailer relative to
+// the end of the header.
+func dataSectionSize(totalLen int64, chunkSize int) int64 {
+	if totalLen <= 0 {
+		return 0
+	}
+	n := (totalLen + int64(chunkSize) - 1) / int64(chunkSize)
+	full := n - 1
+	lastLen := totalLen - full*int64(chunkSize)
+	return full*(int64(chunkSize)+int64(authTagSize)) + lastLen + int64(authTagSize)
+}
+
+// verifyChunk climbs chunkIndex's sibling path from its leaf tag up to the
+// root, reading each sibling from the cheapest place it's stored: the
+// sibling chunk's own footer tag at level 0, or the Merkle trailer at every
+// level above that. It never reads or hashes a tag outside that path, so
+// authenticating one chunk costs O(log N) reads regardless of file size.
+func (a *AuthenticatedReaderAt) verifyChunk(chunkIndex int64, leaf []byte) (bool, error) {
+	idx := chunkIndex
+	cur := leaf
+
+	for level := 0; level < len(a.levelSizes)-1; level++ {
+		n := a.levelSizes[level]
+
+		var siblingIdx int64
+		if idx%2 == 0 {
+			siblingIdx = idx + 1
+			if siblingIdx >= n {
+				siblingIdx = idx
+			}
+		} else {
+			siblingIdx = idx - 1
+		}
+
+		var sibling []byte
+		switch {
+		case siblingIdx == idx:
+			sibling = cur
+		case level == 0:
+			sibling = make([]byte, authTagSize)
+			off := a.chunkOffset(siblingIdx) + a.chunkPlainLen(siblingIdx)
+			if _, err := a.r.ReadAt(sibling, off); err != nil {
+				return false, fmt.Errorf("aesctr: reading sibling tag for chunk %d: %w", siblingIdx, err)
+			}
+		default:
+			sibling = make([]byte, authTagSize)
+			off := a.trailerLevelOffset[level] + siblingIdx*int64(authTagSize)
+			if _, err := a.r.ReadAt(sibling, off); err != nil {
+				return false, fmt.Errorf("aesctr: reading merkle level %d node %d: %w", level, siblingIdx, err)
+			}
+		}
+
+		h := sha256.New()
+		if idx%2 == 0 {
+			h.Write(cur)
+			h.Write(sibling)
+		} else {
+			h.Write(sibling)
+			h.Write(cur)
+		}
+		cur = h.Sum(nil)
+		idx /= 2
+	}
+
+	return hmac.Equal(cur, a.h.root), nil
+}
+
+func (a *AuthenticatedReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 || off >= a.h.totalLen {
+		return 0, io.EOF
+	}
+	if off+int64(len(p)) > a.h.totalLen {
+		p = p[:a.h.totalLen-off]
+		err = io.EOF
+	}
+
+	for len(p) > 0 {
+		chunkIndex := off / int64(a.h.chunkSize)
+		chunkOff := off % int64(a.h.chunkSize)
+		plainLen := a.chunkPlainLen(chunkIndex)
+
+		ciphertext := make([]byte, plainLen)
+		if _, e := a.r.ReadAt(ciphertext, a.chunkOffset(chunkIndex)); e != nil {
+			return n, fmt.Errorf("aesctr: reading chunk %d: %w", chunkIndex, e)
+		}
+
+		leaf := chunkTag(a.macKey, chunkIndex, ciphertext)
+		ok, verr := a.verifyChunk(chunkIndex, leaf)
+		if verr != nil {
+			return n, verr
+		}
+		if !ok {
+			return n, ErrAuthentication
+		}
+
+		chunkIV := AddCounter(a.h.iv, uint64(chunkIndex)*uint64(a.blocksPerChunk))
+		plaintext := make([]byte, len(ciphertext))
+		cipher.NewCTR(a.block, chunkIV).XORKeyStream(plaintext, ciphertext)
+
+		c := copy(p, plaintext[chunkOff:])
+		p = p[c:]
+		off += int64(c)
+		n += c
+	}
+	return n, err
+}
+
+// AuthenticatedWriter streams plaintext into the authenticated chunked
+// format read by AuthenticatedReaderAt. The underlying writer must be
+// seekable because the header's total length and Merkle root are only known
+// once Close has flushed the final (possibly partial) chunk.
+type AuthenticatedWriter struct {
+	w      io.WriteSeeker
+	block  cipher.Block
+	macKey []byte
+	iv     []byte
+
+	chunkSize      int
+	blocksPerChunk int64
+
+	buf        []byte
+	chunkIndex int64
+	totalLen   int64
+	tags       [][]byte
+	closed     bool
+}
+
+// NewAuthenticatedWriter returns an AuthenticatedWriter that encrypts with
+// block in CTR mode starting from iv, authenticates each chunkSize-sized
+// chunk with HMAC-SHA256 under macKey, and writes the result to w.
+func NewAuthenticatedWriter(w io.WriteSeeker, block cipher.Block, iv, macKey []byte, chunkSize int) (*AuthenticatedWriter, error) {
+	if len(iv) != block.BlockSize() {
+		return nil, errors.New("aesctr: IV length must equal block size")
+	}
+	if chunkSize <= 0 || chunkSize%block.BlockSize() != 0 {
+		return nil, errors.New("aesctr: chunk size must be a positive multiple of the block size")
+	}
+
+	// Reserve space for the header; it is rewritten with the final total
+	// length and Merkle root once Close has seen every chunk.
+	if err := writeAuthHeader(w, &authHeader{chunkSize: chunkSize, iv: iv, root: merkleRoot(nil)}, macKey); err != nil {
+		return nil, err
+	}
+
+	return &AuthenticatedWriter{
+		w:              w,
+		block:          block,
+		macKey:         dup(macKey),
+		iv:             dup(iv),
+		chunkSize:      chunkSize,
+		blocksPerChunk: int64(chunkSize) / int64(block.BlockSize()),
+	}, nil
+}
+
+func (a *AuthenticatedWriter) flushChunk(plain []byte) error {
+	chunkIV := AddCounter(a.iv, uint64(a.chunkIndex)*uint64(a.blocksPerChunk))
+	ciphertext := make([]byte, len(plain))
+	cipher.NewCTR(a.block, chunkIV).XORKeyStream(ciphertext, plain)
+
+	tag := chunkTag(a.macKey, a.chunkIndex, ciphertext)
+
+	if _, err := a.w.Write(ciphertext); err != nil {
+		return err
+	}
+	if _, err := a.w.Write(tag); err != nil {
+		return err
+	}
+
+	a.tags = append(a.tags, tag)
+	a.totalLen += int64(len(plain))
+	a.chunkIndex++
+	return nil
+}
+
+func (a *AuthenticatedWriter) Write(p []byte) (n int, err error) {
+	if a.closed {
+		return 0, errors.New("aesctr: write to closed AuthenticatedWriter")
+	}
+
+	n = len(p)
+	for len(p) > 0 {
+		free := a.chunkSize - len(a.buf)
+		take := len(p)
+		if take > free {
+			take = free
+		}
+		a.buf = append(a.buf, p[:take]...)
+		p = p[take:]
+
+		if len(a.buf) == a.chunkSize {
+			if err = a.flushChunk(a.buf); err != nil {
+				return
+			}
+			a.buf = a.buf[:0]
+		}
+	}
+	return n, nil
+}
+
+// Close flushes any buffered partial chunk, writes the Merkle tree's
+// internal levels as a trailer (the leaves are already on disk as chunk
+// footer tags, and the root goes in the header, so only the levels between
+// the two need a home), and rewrites the header with the final total length
+// and root. It does not close the underlying writer.
+func (a *AuthenticatedWriter) Close() error {
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+
+	if len(a.buf) > 0 {
+		if err := a.flushChunk(a.buf); err != nil {
+			return err
+		}
+		a.buf = nil
+	}
+
+	levels := merkleLevels(a.tags)
+	if len(levels) > 1 {
+		for _, level := range levels[1 : len(levels)-1] {
+			for _, node := range level {
+				if _, err := a.w.Write(node); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	root := levels[len(levels)-1][0]
+
+	if _, err := a.w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return writeAuthHeader(a.w, &authHeader{
+		chunkSize: a.chunkSize,
+		totalLen:  a.totalLen,
+		iv:        a.iv,
+		root:      root,
+	}, a.macKey)
+}
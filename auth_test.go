@@ -0,0 +1,196 @@
+package aesctr
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+)
+
+func newAuthFixture(t *testing.T, plaintext []byte) (*bytes.Reader, cipher.Block, []byte, []byte) {
+	t.Helper()
+	key := []byte("thisIsJustARandomStringOfChars=)")
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("Could not create cipher: %v", err)
+	}
+
+	iv := make([]byte, block.BlockSize())
+	for i := range iv {
+		iv[i] = byte(i)
+	}
+	macKey := []byte("mac-key-mac-key-mac-key-mac-key")
+
+	var buf seekableBuffer
+	w, err := NewAuthenticatedWriter(&buf, block, iv, macKey, 4096)
+	if err != nil {
+		t.Fatalf("NewAuthenticatedWriter: %v", err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	return bytes.NewReader(buf.Bytes()), block, iv, macKey
+}
+
+// Test round-trip of random-access reads through the authenticated format.
+func TestAuthenticatedRoundTrip(t *testing.T) {
+	plaintext := make([]byte, 100*1024)
+	for i := range plaintext {
+		plaintext[i] = byte(i % 256)
+	}
+
+	r, block, _, macKey := newAuthFixture(t, plaintext)
+
+	reader, err := NewAuthenticatedReaderAt(r, block, macKey)
+	if err != nil {
+		t.Fatalf("NewAuthenticatedReaderAt: %v", err)
+	}
+
+	chunk := make([]byte, 777)
+	for offset := 0; offset+len(chunk) <= len(plaintext); offset += 4001 {
+		n, err := reader.ReadAt(chunk, int64(offset))
+		if err != nil {
+			t.Fatalf("ReadAt(%d): %v", offset, err)
+		}
+		if n != len(chunk) || !bytes.Equal(chunk, plaintext[offset:offset+len(chunk)]) {
+			t.Fatalf("ReadAt(%d) returned wrong data", offset)
+		}
+	}
+}
+
+// Test that a flipped ciphertext byte is detected rather than silently
+// decrypted into corrupted plaintext.
+func TestAuthenticatedDetectsTampering(t *testing.T) {
+	plaintext := make([]byte, 10*1024)
+	for i := range plaintext {
+		plaintext[i] = byte(i % 256)
+	}
+
+	r, block, _, macKey := newAuthFixture(t, plaintext)
+
+	raw := make([]byte, r.Size())
+	if _, err := r.ReadAt(raw, 0); err != nil {
+		t.Fatalf("reading back raw bytes: %v", err)
+	}
+	raw[authHeaderSize+10] ^= 0xff // flip a ciphertext byte in chunk 0
+
+	tampered, err := NewAuthenticatedReaderAt(bytes.NewReader(raw), block, macKey)
+	if err != nil {
+		t.Fatalf("NewAuthenticatedReaderAt: %v", err)
+	}
+
+	chunk := make([]byte, 16)
+	if _, err := tampered.ReadAt(chunk, 0); err != ErrAuthentication {
+		t.Fatalf("ReadAt on tampered chunk = %v, want ErrAuthentication", err)
+	}
+}
+
+// Test that a flipped header byte (e.g. the IV) is rejected at open time
+// instead of silently decrypting every chunk with the wrong IV.
+func TestAuthenticatedDetectsHeaderTampering(t *testing.T) {
+	plaintext := make([]byte, 10*1024)
+	for i := range plaintext {
+		plaintext[i] = byte(i % 256)
+	}
+
+	r, block, _, macKey := newAuthFixture(t, plaintext)
+
+	raw := make([]byte, r.Size())
+	if _, err := r.ReadAt(raw, 0); err != nil {
+		t.Fatalf("reading back raw bytes: %v", err)
+	}
+	raw[17] ^= 0xff // flip a byte of the header's IV field
+
+	if _, err := NewAuthenticatedReaderAt(bytes.NewReader(raw), block, macKey); err == nil {
+		t.Fatalf("NewAuthenticatedReaderAt accepted a tampered header")
+	}
+}
+
+// Test that shrinking totalLen in the header to drop trailing chunks is
+// rejected, since the header tag covers totalLen.
+func TestAuthenticatedDetectsTruncation(t *testing.T) {
+	plaintext := make([]byte, 10*1024)
+	for i := range plaintext {
+		plaintext[i] = byte(i % 256)
+	}
+
+	r, block, _, macKey := newAuthFixture(t, plaintext)
+
+	raw := make([]byte, r.Size())
+	if _, err := r.ReadAt(raw, 0); err != nil {
+		t.Fatalf("reading back raw bytes: %v", err)
+	}
+	raw[8+6] ^= 0x08 // shrink totalLen from 10240 to 8192, dropping the last chunk, without recomputing the header tag
+
+	if _, err := NewAuthenticatedReaderAt(bytes.NewReader(raw), block, macKey); err == nil {
+		t.Fatalf("NewAuthenticatedReaderAt accepted a tampered totalLen")
+	}
+}
+
+// Test that tampering with an internal Merkle trailer node (rather than a
+// chunk's own ciphertext or footer tag) is still detected, since ReadAt
+// walks the sibling path up to the header's root instead of trusting a
+// cached tag table.
+func TestAuthenticatedDetectsTrailerTampering(t *testing.T) {
+	// 3 chunks of 4096 bytes gives a non-trivial internal Merkle level
+	// (a trailer) for the sibling path to climb through.
+	plaintext := make([]byte, 3*4096)
+	for i := range plaintext {
+		plaintext[i] = byte(i % 256)
+	}
+
+	r, block, _, macKey := newAuthFixture(t, plaintext)
+
+	raw := make([]byte, r.Size())
+	if _, err := r.ReadAt(raw, 0); err != nil {
+		t.Fatalf("reading back raw bytes: %v", err)
+	}
+	raw[len(raw)-1] ^= 0xff // flip a byte in the trailer's last node
+
+	tampered, err := NewAuthenticatedReaderAt(bytes.NewReader(raw), block, macKey)
+	if err != nil {
+		t.Fatalf("NewAuthenticatedReaderAt: %v", err)
+	}
+
+	chunk := make([]byte, 16)
+	if _, err := tampered.ReadAt(chunk, 0); err != ErrAuthentication {
+		t.Fatalf("ReadAt with tampered trailer = %v, want ErrAuthentication", err)
+	}
+}
+
+// seekableBuffer is an in-memory io.WriteSeeker for tests, since
+// *bytes.Buffer does not implement Seek.
+type seekableBuffer struct {
+	buf []byte
+	pos int
+}
+
+func (s *seekableBuffer) Write(p []byte) (int, error) {
+	end := s.pos + len(p)
+	if end > len(s.buf) {
+		s.buf = append(s.buf, make([]byte, end-len(s.buf))...)
+	}
+	copy(s.buf[s.pos:end], p)
+	s.pos = end
+	return len(p), nil
+}
+
+func (s *seekableBuffer) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case 0:
+		s.pos = int(offset)
+	case 1:
+		s.pos += int(offset)
+	case 2:
+		s.pos = len(s.buf) + int(offset)
+	}
+	return int64(s.pos), nil
+}
+
+func (s *seekableBuffer) Bytes() []byte {
+	return s.buf
+}
@@ -0,0 +1,102 @@
+// Cipher Feedback (CFB) mode, decryption only.
+
+// CFB decryption is randomly accessible per block because each plaintext
+// block only depends on the IV (or, for later blocks, the previous
+// ciphertext block) and the corresponding ciphertext block: ReadAt rounds
+// off down to a block boundary, fetches the preceding ciphertext block (or
+// the IV, for the first block) from the underlying ReaderAt, and decrypts
+// forward from there. CFB encryption is not offered here because producing
+// ciphertext block i requires ciphertext block i-1, which doesn't exist yet
+// for a ReaderAt-shaped random-access writer.
+
+package aesctr
+
+import (
+	"crypto/cipher"
+	"io"
+)
+
+type cfbDecrypter struct {
+	b  cipher.Block
+	iv []byte
+	bs int
+
+	r io.ReaderAt
+}
+
+// NewCFBDecrypterReaderAt returns a ReaderAt which decrypts using the given
+// Block in cipher feedback (CFB) mode. The length of iv must be the same as
+// the Block's block size.
+func NewCFBDecrypterReaderAt(block cipher.Block, iv []byte, reader io.ReaderAt) io.ReaderAt {
+	if len(iv) != block.BlockSize() {
+		panic("cipher.NewCFBDecrypter: IV length must equal block size")
+	}
+
+	return &cfbDecrypter{
+		b:  block,
+		iv: dup(iv),
+		bs: block.BlockSize(),
+		r:  reader,
+	}
+}
+
+func (x *cfbDecrypter) ReadAt(p []byte, off int64) (n int, err error) {
+	bs := int64(x.bs)
+	bOff := off % bs
+	bStart := off - bOff
+
+	var prev []byte
+	if bStart == 0 {
+		prev = x.iv
+	} else {
+		prev = make([]byte, bs)
+		if _, err = x.r.ReadAt(prev, bStart-bs); err != nil {
+			return 0, err
+		}
+	}
+
+	total := bOff + int64(len(p))
+	nblocks := (total + bs - 1) / bs
+
+	// The underlying file's length is usually not a multiple of the block
+	// size, so the final block read here may come back short. ReadAt is
+	// still expected to succeed as long as the bytes p actually asked for
+	// are all present, so only the bytes cn actually returned are decrypted
+	// below; io.EOF/io.ErrUnexpectedEOF past that point is not fatal by
+	// itself.
+	ciphertext := make([]byte, nblocks*bs)
+	cn, cerr := x.r.ReadAt(ciphertext, bStart)
+	if cerr != nil && cerr != io.EOF && cerr != io.ErrUnexpectedEOF {
+		return 0, cerr
+	}
+	ciphertext = ciphertext[:cn]
+
+	plaintext := make([]byte, len(ciphertext))
+	keystream := make([]byte, bs)
+	cur := prev
+	for i := int64(0); i*bs < int64(len(ciphertext)); i++ {
+		end := (i + 1) * bs
+		if end > int64(len(ciphertext)) {
+			end = int64(len(ciphertext))
+		}
+		ct := ciphertext[i*bs : end]
+		x.b.Encrypt(keystream, cur)
+		xorBytes(plaintext[i*bs:end], ct, keystream)
+		if end-i*bs == bs {
+			cur = ct
+		}
+	}
+
+	if int64(len(plaintext)) <= bOff {
+		return 0, cerr
+	}
+
+	n = copy(p, plaintext[bOff:])
+	if n < len(p) {
+		if cerr == nil {
+			cerr = io.ErrUnexpectedEOF
+		}
+		return n, cerr
+	}
+	return n, nil
+}
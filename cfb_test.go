@@ -0,0 +1,91 @@
+package aesctr
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+)
+
+// Test CFB decryption of large input, including random-access ReadAt calls.
+func TestCFBDecrypt(t *testing.T) {
+	key := []byte("thisIsJustARandomStringOfChars=)")
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Errorf("Could not create cipher: %v", err)
+	}
+
+	plaintext := make([]byte, 10*1024*1024)
+	for i := 0; i < len(plaintext); i++ {
+		plaintext[i] = byte(i % 256)
+	}
+
+	iv := make([]byte, block.BlockSize())
+	for i := 0; i < len(iv); i++ {
+		iv[i] = byte(i)
+	}
+
+	encrypter := cipher.NewCFBEncrypter(block, iv)
+	ciphertext := make([]byte, len(plaintext))
+	encrypter.XORKeyStream(ciphertext, plaintext)
+
+	bReader := bytes.NewReader(ciphertext)
+	decrypter := NewCFBDecrypterReaderAt(block, iv, bReader)
+
+	chunk := make([]byte, 1024)
+	for i := 0; i < (len(plaintext)/257)-5; i++ {
+		offset := i * 257
+		decrypter.ReadAt(chunk, int64(offset))
+		for j := 0; j < len(chunk); j++ {
+			if chunk[j] != plaintext[offset+j] {
+				t.Fatalf("Decrypted chunk does not match at offset %d", offset)
+			}
+		}
+	}
+}
+
+// Test that a ReadAt entirely within the file, but whose last block is
+// shorter than the block size, succeeds instead of failing with io.EOF.
+func TestCFBDecryptPartialFinalBlock(t *testing.T) {
+	key := []byte("thisIsJustARandomStringOfChars=)")
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("Could not create cipher: %v", err)
+	}
+
+	plaintext := make([]byte, 100)
+	for i := range plaintext {
+		plaintext[i] = byte(i)
+	}
+
+	iv := make([]byte, block.BlockSize())
+	for i := range iv {
+		iv[i] = byte(i)
+	}
+
+	encrypter := cipher.NewCFBEncrypter(block, iv)
+	ciphertext := make([]byte, len(plaintext))
+	encrypter.XORKeyStream(ciphertext, plaintext)
+
+	decrypter := NewCFBDecrypterReaderAt(block, iv, bytes.NewReader(ciphertext))
+
+	buf := make([]byte, 20)
+	n, err := decrypter.ReadAt(buf, 80)
+	if err != nil {
+		t.Fatalf("ReadAt(80): n=%d, err=%v, want n=20, err=nil", n, err)
+	}
+	if n != len(buf) || !bytes.Equal(buf, plaintext[80:100]) {
+		t.Fatalf("ReadAt(80) returned wrong data")
+	}
+
+	// A read that runs past the end of the file should still return the
+	// bytes that exist, followed by io.EOF.
+	short := make([]byte, 30)
+	n, err = decrypter.ReadAt(short, 80)
+	if n != 20 || err == nil {
+		t.Fatalf("ReadAt(80) past EOF = n=%d, err=%v, want n=20, err=non-nil", n, err)
+	}
+	if !bytes.Equal(short[:20], plaintext[80:100]) {
+		t.Fatalf("ReadAt(80) past EOF returned wrong data")
+	}
+}
@@ -0,0 +1,65 @@
+package aesctr
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAddCounter(t *testing.T) {
+	iv := make([]byte, 16)
+	for i := range iv {
+		iv[i] = byte(i)
+	}
+
+	got := AddCounter(iv, 1)
+	want := dup(iv)
+	want[15]++
+	if !bytes.Equal(got, want) {
+		t.Fatalf("AddCounter(iv, 1) = %x, want %x", got, want)
+	}
+
+	// Carry from the low word into the high word.
+	iv2 := make([]byte, 16)
+	for i := 8; i < 16; i++ {
+		iv2[i] = 0xff
+	}
+	got2 := AddCounter(iv2, 1)
+	want2 := make([]byte, 16)
+	want2[7] = 1
+	if !bytes.Equal(got2, want2) {
+		t.Fatalf("AddCounter carry = %x, want %x", got2, want2)
+	}
+}
+
+func TestAddCounterWidth(t *testing.T) {
+	iv := make([]byte, 16)
+	for i := 8; i < 16; i++ {
+		iv[i] = 0xff
+	}
+
+	// With a 32-bit counter, adding 1 to an all-ones low word must wrap
+	// within the low 4 bytes only, leaving the rest of the IV untouched.
+	got := AddCounterWidth(iv, 1, CounterWidth32)
+	want := dup(iv)
+	want[12], want[13], want[14], want[15] = 0, 0, 0, 0
+	if !bytes.Equal(got, want) {
+		t.Fatalf("AddCounterWidth(iv, 1, 32) = %x, want %x", got, want)
+	}
+
+	// With a 64-bit counter, the same input carries across the whole low
+	// word but must not touch the high (nonce) word.
+	got64 := AddCounterWidth(iv, 1, CounterWidth64)
+	want64 := make([]byte, 16)
+	if !bytes.Equal(got64, want64) {
+		t.Fatalf("AddCounterWidth(iv, 1, 64) = %x, want %x", got64, want64)
+	}
+}
+
+func TestAddCounterRequires16ByteIV(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic for short IV")
+		}
+	}()
+	AddCounter(make([]byte, 8), 1)
+}
@@ -17,67 +17,106 @@ package aesctr
 import (
 	"crypto/cipher"
 	"io"
-	"sync"
 )
 
+// ctrAble is implemented by cipher.Blocks that can provide an optimized
+// implementation of CTR through the cipher.Stream interface. See
+// crypto/aes.aesCipherAsm for the upstream equivalent used by the
+// amd64/s390x/arm64 AES-NI and crypto-extension fast paths.
+type ctrAble interface {
+	NewCTR(iv []byte) cipher.Stream
+}
+
+// ctr holds the immutable configuration shared by every ReadAt call. It is
+// never mutated after construction, which is what makes ReadAt safe to call
+// concurrently from multiple goroutines, as io.ReaderAt requires.
 type ctr struct {
-	b       cipher.Block
-	iv      []byte
+	b     cipher.Block
+	iv    []byte
+	bs    int
+	width CounterWidth
+
+	r io.ReaderAt
+}
+
+// ctrState is the per-call keystream state. Each ReadAt allocates its own
+// ctrState on the stack/heap, so concurrent callers never share a counter or
+// keystream buffer.
+type ctrState struct {
 	ctr     []byte
 	out     []byte
 	outUsed int
 
-	r  io.ReaderAt
-	rl sync.Mutex
+	stream cipher.Stream // non-nil when the ctrAble fast path is in use
 }
 
 const streamBufferSize = 512
 
 // NewCTRReaderAt returns a ReaderAt which encrypts/decrypts using the given Block in
 // counter mode. The length of iv must be the same as the Block's block size.
+//
+// The returned ReaderAt is safe for concurrent use by multiple goroutines:
+// each ReadAt computes its own keystream starting from the counter implied by
+// its offset, without sharing mutable state with any other call.
 func NewCTRReaderAt(block cipher.Block, iv []byte, reader io.ReaderAt) io.ReaderAt {
+	return NewCTRReaderAtWidth(block, iv, reader, CounterWidth128)
+}
+
+// NewCTRReaderAtWidth is like NewCTRReaderAt but only increments the low
+// width bits of the IV for each block, leaving any higher bits fixed. Use
+// this to interoperate with GCM-style or nonce||counter layouts that reserve
+// part of the IV as a fixed nonce. See CounterWidth.
+func NewCTRReaderAtWidth(block cipher.Block, iv []byte, reader io.ReaderAt, width CounterWidth) io.ReaderAt {
 	if len(iv) != block.BlockSize() {
 		panic("cipher.NewCTR: IV length must equal block size")
 	}
+
 	bufSize := streamBufferSize
 	if bufSize < block.BlockSize() {
 		bufSize = block.BlockSize()
 	}
+
 	return &ctr{
-		b:       block,
-		iv:      dup(iv),
-		ctr:     dup(iv),
-		out:     make([]byte, 0, bufSize),
-		outUsed: 0,
-		r:       reader,
+		b:     block,
+		iv:    dup(iv),
+		bs:    bufSize,
+		width: width,
+		r:     reader,
 	}
 }
 
-func (x *ctr) refill() {
+func (x *ctrState) refill(c *ctr) {
 	remain := len(x.out) - x.outUsed
 	copy(x.out, x.out[x.outUsed:])
 	x.out = x.out[:cap(x.out)]
-	bs := x.b.BlockSize()
-	for remain <= len(x.out)-bs {
-		x.b.Encrypt(x.out[remain:], x.ctr)
-		remain += bs
 
-		// Increment counter
-		for i := len(x.ctr) - 1; i >= 0; i-- {
-			x.ctr[i]++
-			if x.ctr[i] != 0 {
-				break
-			}
+	if x.stream != nil {
+		// The ctrAble fast path generates keystream directly; there is no
+		// per-block counter to increment by hand. XORKeyStream against a
+		// zeroed tail yields the raw keystream.
+		tail := x.out[remain:]
+		for i := range tail {
+			tail[i] = 0
 		}
+		x.stream.XORKeyStream(tail, tail)
+		x.outUsed = 0
+		return
+	}
+
+	bs := c.b.BlockSize()
+	for remain <= len(x.out)-bs {
+		c.b.Encrypt(x.out[remain:], x.ctr)
+		remain += bs
+		incrementCounter(x.ctr, c.width)
 	}
 	x.out = x.out[:remain]
 	x.outUsed = 0
 }
 
-func (x *ctr) XORKeyStream(dst, src []byte) {
+func (x *ctrState) XORKeyStream(dst, src []byte, c *ctr) {
 	for len(src) > 0 {
-		if x.outUsed >= len(x.out)-x.b.BlockSize() {
-			x.refill()
+		if x.outUsed >= len(x.out)-c.b.BlockSize() {
+			x.refill(c)
 		}
 		n := xorBytes(dst, src, x.out[x.outUsed:])
 		dst = dst[n:]
@@ -86,75 +125,72 @@ func (x *ctr) XORKeyStream(dst, src []byte) {
 	}
 }
 
-func (x *ctr) ReadAt(p []byte, off int64) (n int, err error) {
-	// Read from start of block
-	bOff := off % int64(x.b.BlockSize())
+func (c *ctr) ReadAt(p []byte, off int64) (n int, err error) {
+	// ctrState to read from start of block
+	bOff := off % int64(c.b.BlockSize())
 	bStart := off - bOff
-	bN := bStart / int64(x.b.BlockSize())
+	bN := bStart / int64(c.b.BlockSize())
 
-	x.rl.Lock()
-	defer x.rl.Unlock()
+	state := initCTR(c, bN, bOff)
 
-	x.setCTR(bN) // We could have a different buffer for each reader
-
-	n, err = x.r.ReadAt(p, off)
+	n, err = c.r.ReadAt(p, off)
 	if err != nil {
 		return
 	}
 
-	x.XORKeyStream(p, p)
+	state.XORKeyStream(p, p, c)
 	return
 }
 
-// Utility routines
-
-func (x *ctr) setCTR(bN int64) {
-	x.outUsed = 0
+func initCTR(c *ctr, bN, bOff int64) *ctrState {
+	x := &ctrState{
+		out:     make([]byte, 0, c.bs),
+		outUsed: 0,
+	}
 
-	// Fill ctr
-	// TODO (br): This can be greatly improved, it is just for correctness testing
-	copy(x.ctr, x.iv)
-	for j := int64(0); j < bN; j++ {
-		for i := len(x.ctr) - 1; i >= 0; i-- {
-			x.ctr[i]++
-			if x.ctr[i] != 0 {
-				break
+	if len(c.iv) == 16 {
+		// Fast path: O(1) seek using big-endian 128-bit arithmetic, rather
+		// than looping bN times or carrying byte by byte.
+		x.ctr = AddCounterWidth(c.iv, uint64(bN), c.width)
+	} else {
+		// Generic block sizes can't use the uint64-pair representation
+		// above; fall back to carrying the addition byte by byte from the
+		// least significant byte.
+		x.ctr = dup(c.iv)
+		for i := len(x.ctr) - 1; bN != 0 && i >= 0; i-- {
+			mod := byte(bN % 256)
+			bN >>= 8
+
+			tmp := x.ctr[i]
+			x.ctr[i] += mod
+			if x.ctr[i] < tmp { // carry over
+				bN++
 			}
 		}
 	}
 
-	// Fill out
-	remain := 0
-	bs := x.b.BlockSize()
-	for remain <= len(x.out)-bs {
-		x.b.Encrypt(x.out[remain:], x.ctr)
-		remain += bs
-
-		// Increment counter
-		for i := len(x.ctr) - 1; i >= 0; i-- {
-			x.ctr[i]++
-			if x.ctr[i] != 0 {
-				break
-			}
+	if c.width == CounterWidth128 {
+		// The ctrAble fast path always increments the full 128-bit counter
+		// per block, so it can't honor a narrower width's wraparound; fall
+		// back to driving block.Encrypt by hand (via incrementCounter) for
+		// any non-default width.
+		if ca, ok := c.b.(ctrAble); ok {
+			// Prefer the Block's own hardware-accelerated CTR stream
+			// (AES-NI on amd64, the crypto extensions on arm64, KM/KMCTR on
+			// s390x) over driving block.Encrypt ourselves.
+			x.stream = ca.NewCTR(x.ctr)
 		}
 	}
 
-	x.out = x.out[:remain]
+	x.refill(c)
+	x.out = x.out[bOff:]
+	return x
 }
 
+// Utility routines
+
 func dup(p []byte) []byte {
 	q := make([]byte, len(p))
 	copy(q, p)
 	return q
 }
-
-func xorBytes(dst, a, b []byte) int {
-	n := len(a)
-	if len(b) < n {
-		n = len(b)
-	}
-	for i := 0; i < n; i++ {
-		dst[i] = a[i] ^ b[i]
-	}
-	return n
-}
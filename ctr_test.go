@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"sync"
 	"testing"
 )
 
@@ -44,3 +45,134 @@ func TestCipherEncryptDecrypt(t *testing.T) {
 	}
 
 }
+
+// Test that streaming ReadAt honors CounterWidth across a low-counter wrap,
+// not just the O(1)-seek path: the per-block increment in the block.Encrypt
+// loop (non-128 widths never use the ctrAble fast path) must wrap within
+// the low width bits rather than carrying into the fixed nonce bits above
+// them.
+func TestCTRReaderAtWidthWrapsOnStream(t *testing.T) {
+	key := []byte("thisIsJustARandomStringOfChars=)")
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("Could not create cipher: %v", err)
+	}
+
+	// A low 32-bit counter one block away from wrapping back to 0, with a
+	// nonzero high 96 bits that must stay fixed across the wrap.
+	iv := make([]byte, block.BlockSize())
+	for i := 0; i < 12; i++ {
+		iv[i] = byte(i + 1)
+	}
+	iv[12], iv[13], iv[14], iv[15] = 0xff, 0xff, 0xff, 0xfe
+
+	const numBlocks = 4
+	plaintext := make([]byte, numBlocks*block.BlockSize())
+	for i := range plaintext {
+		plaintext[i] = byte(i)
+	}
+
+	// Reference ciphertext computed by independently encrypting each block
+	// against AddCounterWidth(iv, i, CounterWidth32), which is already
+	// known-correct for seeking.
+	ciphertext := make([]byte, len(plaintext))
+	for i := 0; i < numBlocks; i++ {
+		blockIV := AddCounterWidth(iv, uint64(i), CounterWidth32)
+		keystream := make([]byte, block.BlockSize())
+		block.Encrypt(keystream, blockIV)
+		xorBytes(ciphertext[i*block.BlockSize():(i+1)*block.BlockSize()], plaintext[i*block.BlockSize():(i+1)*block.BlockSize()], keystream)
+	}
+
+	decrypter := NewCTRReaderAtWidth(block, iv, bytes.NewReader(ciphertext), CounterWidth32)
+
+	got := make([]byte, len(plaintext))
+	if _, err := decrypter.ReadAt(got, 0); err != nil {
+		t.Fatalf("ReadAt: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("streamed plaintext across counter wrap = %x, want %x", got, plaintext)
+	}
+}
+
+// Test that ReadAt can be called concurrently from many goroutines against
+// disjoint ranges without the results clobbering each other.
+func TestCipherEncryptDecryptConcurrent(t *testing.T) {
+	key := []byte("thisIsJustARandomStringOfChars=)")
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Errorf("Could not create cipher: %v", err)
+	}
+
+	plaintext := make([]byte, 10*1024*1024)
+	for i := 0; i < len(plaintext); i++ {
+		plaintext[i] = byte(i % 256)
+	}
+
+	iv := make([]byte, block.BlockSize())
+	for i := 0; i < len(iv); i++ {
+		iv[i] = byte(i)
+	}
+
+	encrypter := cipher.NewCTR(block, iv)
+	ciphertext := make([]byte, len(plaintext))
+	encrypter.XORKeyStream(ciphertext, plaintext)
+
+	bReader := bytes.NewReader(ciphertext)
+	decrypter := NewCTRReaderAt(block, iv, bReader)
+
+	const chunkSize = 1024
+	const goroutines = 16
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			chunk := make([]byte, chunkSize)
+			for offset := g * chunkSize; offset+chunkSize <= len(plaintext); offset += goroutines * chunkSize {
+				if _, err := decrypter.ReadAt(chunk, int64(offset)); err != nil {
+					t.Errorf("ReadAt(%d): %v", offset, err)
+					return
+				}
+				if !bytes.Equal(chunk, plaintext[offset:offset+chunkSize]) {
+					t.Errorf("Decrypted chunk at offset %d does not match", offset)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+// Benchmark parallel ReadAt throughput to show that disjoint ranges scale
+// with GOMAXPROCS now that ReadAt no longer serializes on a shared lock.
+func BenchmarkReadAtParallel(b *testing.B) {
+	key := []byte("thisIsJustARandomStringOfChars=)")
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		b.Fatalf("Could not create cipher: %v", err)
+	}
+
+	plaintext := make([]byte, 64*1024*1024)
+	iv := make([]byte, block.BlockSize())
+
+	encrypter := cipher.NewCTR(block, iv)
+	ciphertext := make([]byte, len(plaintext))
+	encrypter.XORKeyStream(ciphertext, plaintext)
+
+	bReader := bytes.NewReader(ciphertext)
+	decrypter := NewCTRReaderAt(block, iv, bReader)
+
+	const chunkSize = 64 * 1024
+
+	b.SetBytes(chunkSize)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		chunk := make([]byte, chunkSize)
+		offset := int64(0)
+		for pb.Next() {
+			decrypter.ReadAt(chunk, offset%int64(len(plaintext)-chunkSize))
+			offset += chunkSize
+		}
+	})
+}
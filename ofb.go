@@ -0,0 +1,129 @@
+// Output Feedback (OFB) mode.
+
+// OFB converts a block cipher into a stream cipher by repeatedly encrypting
+// an IV-derived value and xoring the resulting stream of data with the
+// input. Unlike CTR, the keystream is not seekable in O(1): block i's
+// keystream is the IV encrypted i+1 times in a row, so reaching a large
+// offset from scratch costs O(offset). See NewOFBReaderAtCheckpointed for a
+// way around that.
+
+package aesctr
+
+import (
+	"crypto/cipher"
+	"io"
+)
+
+// OFBCheckpointTable caches OFB keystream state every Interval blocks, so
+// ReadAt at a large offset only has to replay from the nearest checkpoint
+// rather than from the IV. Build one with BuildOFBCheckpoints.
+type OFBCheckpointTable struct {
+	Interval int
+	Blocks   [][]byte // Blocks[i] is the keystream state after i*Interval blocks
+}
+
+// BuildOFBCheckpoints materializes an OFBCheckpointTable for the given block
+// and IV, caching the keystream state every interval blocks. count is the
+// number of checkpoints to generate, covering up to count*interval blocks.
+func BuildOFBCheckpoints(block cipher.Block, iv []byte, interval, count int) *OFBCheckpointTable {
+	table := &OFBCheckpointTable{
+		Interval: interval,
+		Blocks:   make([][]byte, count),
+	}
+
+	state := dup(iv)
+	table.Blocks[0] = dup(state)
+	for i := 1; i < count; i++ {
+		for j := 0; j < interval; j++ {
+			block.Encrypt(state, state)
+		}
+		table.Blocks[i] = dup(state)
+	}
+	return table
+}
+
+type ofb struct {
+	b  cipher.Block
+	iv []byte
+	bs int
+
+	checkpoints *OFBCheckpointTable
+
+	r io.ReaderAt
+}
+
+// NewOFBReaderAt returns a ReaderAt which encrypts/decrypts using the given
+// Block in output feedback (OFB) mode. The length of iv must be the same as
+// the Block's block size.
+//
+// Every ReadAt replays the keystream chain from the IV, so it costs
+// O(off/BlockSize) block encryptions. For random access over large files,
+// use NewOFBReaderAtCheckpointed instead.
+func NewOFBReaderAt(block cipher.Block, iv []byte, reader io.ReaderAt) io.ReaderAt {
+	return NewOFBReaderAtCheckpointed(block, iv, reader, nil)
+}
+
+// NewOFBReaderAtCheckpointed is like NewOFBReaderAt, but uses checkpoints
+// (see BuildOFBCheckpoints) to avoid replaying the whole keystream chain
+// from the IV on every seek. checkpoints may be nil, in which case this is
+// equivalent to NewOFBReaderAt.
+func NewOFBReaderAtCheckpointed(block cipher.Block, iv []byte, reader io.ReaderAt, checkpoints *OFBCheckpointTable) io.ReaderAt {
+	if len(iv) != block.BlockSize() {
+		panic("cipher.NewOFB: IV length must equal block size")
+	}
+
+	return &ofb{
+		b:           block,
+		iv:          dup(iv),
+		bs:          block.BlockSize(),
+		checkpoints: checkpoints,
+		r:           reader,
+	}
+}
+
+// seed returns the OFB state just before the keystream block for block
+// index bN is computed, starting from the nearest checkpoint at or before
+// bN (or the IV, if there is none).
+func (x *ofb) seed(bN int64) []byte {
+	state := dup(x.iv)
+	from := int64(0)
+
+	if x.checkpoints != nil && x.checkpoints.Interval > 0 {
+		idx := bN / int64(x.checkpoints.Interval)
+		if last := int64(len(x.checkpoints.Blocks)) - 1; idx > last {
+			idx = last
+		}
+		if idx > 0 {
+			state = dup(x.checkpoints.Blocks[idx])
+			from = idx * int64(x.checkpoints.Interval)
+		}
+	}
+
+	for i := from; i < bN; i++ {
+		x.b.Encrypt(state, state)
+	}
+	return state
+}
+
+func (x *ofb) ReadAt(p []byte, off int64) (n int, err error) {
+	bs := int64(x.bs)
+	bOff := off % bs
+	bStart := off - bOff
+	bN := bStart / bs
+
+	total := bOff + int64(len(p))
+	nblocks := (total + bs - 1) / bs
+
+	keystream := make([]byte, nblocks*bs)
+	state := x.seed(bN)
+	for i := int64(0); i < nblocks; i++ {
+		x.b.Encrypt(state, state)
+		copy(keystream[i*bs:(i+1)*bs], state)
+	}
+
+	// The underlying reader may return a short read at EOF; decrypt the n
+	// bytes actually read rather than discarding them.
+	n, err = x.r.ReadAt(p, off)
+	xorBytes(p[:n], p[:n], keystream[bOff:bOff+int64(n)])
+	return
+}
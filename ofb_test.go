@@ -0,0 +1,117 @@
+package aesctr
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+)
+
+// Test OFB Encrypt and Decrypt at scattered offsets. Without a checkpoint
+// table each ReadAt replays the keystream chain from the IV, so unlike the
+// CTR/CFB tests this uses a much smaller buffer to keep the test fast.
+func TestOFBEncryptDecrypt(t *testing.T) {
+	key := []byte("thisIsJustARandomStringOfChars=)")
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Errorf("Could not create cipher: %v", err)
+	}
+
+	plaintext := make([]byte, 256*1024)
+	for i := 0; i < len(plaintext); i++ {
+		plaintext[i] = byte(i % 256)
+	}
+
+	iv := make([]byte, block.BlockSize())
+	for i := 0; i < len(iv); i++ {
+		iv[i] = byte(i)
+	}
+
+	encrypter := cipher.NewOFB(block, iv)
+	ciphertext := make([]byte, len(plaintext))
+	encrypter.XORKeyStream(ciphertext, plaintext)
+
+	bReader := bytes.NewReader(ciphertext)
+	decrypter := NewOFBReaderAt(block, iv, bReader)
+
+	chunk := make([]byte, 1024)
+	for offset := 0; offset+len(chunk) <= len(plaintext); offset += 4001 {
+		decrypter.ReadAt(chunk, int64(offset))
+		for j := 0; j < len(chunk); j++ {
+			if chunk[j] != plaintext[offset+j] {
+				t.Fatalf("Decrypted chunk does not match at offset %d", offset)
+			}
+		}
+	}
+}
+
+// Test that a ReadAt whose requested region runs past EOF still decrypts the
+// bytes that were actually read, instead of returning raw ciphertext.
+func TestOFBDecryptShortReadAtEOF(t *testing.T) {
+	key := []byte("thisIsJustARandomStringOfChars=)")
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("Could not create cipher: %v", err)
+	}
+
+	plaintext := make([]byte, 100)
+	for i := range plaintext {
+		plaintext[i] = byte(i)
+	}
+
+	iv := make([]byte, block.BlockSize())
+	for i := range iv {
+		iv[i] = byte(i)
+	}
+
+	encrypter := cipher.NewOFB(block, iv)
+	ciphertext := make([]byte, len(plaintext))
+	encrypter.XORKeyStream(ciphertext, plaintext)
+
+	decrypter := NewOFBReaderAt(block, iv, bytes.NewReader(ciphertext))
+
+	buf := make([]byte, 30)
+	n, err := decrypter.ReadAt(buf, 80)
+	if n != 20 || err == nil {
+		t.Fatalf("ReadAt(80) past EOF = n=%d, err=%v, want n=20, err=non-nil", n, err)
+	}
+	if !bytes.Equal(buf[:20], plaintext[80:100]) {
+		t.Fatalf("ReadAt(80) past EOF returned wrong data, got %x, want %x", buf[:20], plaintext[80:100])
+	}
+}
+
+// Test that a checkpoint table produces the same plaintext as seeking from
+// the IV every time.
+func TestOFBEncryptDecryptCheckpointed(t *testing.T) {
+	key := []byte("thisIsJustARandomStringOfChars=)")
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Errorf("Could not create cipher: %v", err)
+	}
+
+	plaintext := make([]byte, 1024*1024)
+	for i := 0; i < len(plaintext); i++ {
+		plaintext[i] = byte(i % 256)
+	}
+
+	iv := make([]byte, block.BlockSize())
+
+	encrypter := cipher.NewOFB(block, iv)
+	ciphertext := make([]byte, len(plaintext))
+	encrypter.XORKeyStream(ciphertext, plaintext)
+
+	bReader := bytes.NewReader(ciphertext)
+	checkpoints := BuildOFBCheckpoints(block, iv, 64, len(plaintext)/(64*block.BlockSize())+1)
+	decrypter := NewOFBReaderAtCheckpointed(block, iv, bReader, checkpoints)
+
+	chunk := make([]byte, 1024)
+	for i := 0; i < (len(plaintext)/257)-5; i++ {
+		offset := i * 257
+		decrypter.ReadAt(chunk, int64(offset))
+		for j := 0; j < len(chunk); j++ {
+			if chunk[j] != plaintext[offset+j] {
+				t.Fatalf("Decrypted chunk does not match at offset %d", offset)
+			}
+		}
+	}
+}
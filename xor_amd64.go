@@ -0,0 +1,25 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build amd64
+
+package aesctr
+
+//go:noescape
+func xorBytesAsm(dst, a, b *byte, n int)
+
+// xorBytes sets dst[i] = a[i] ^ b[i] for i < min(len(a), len(b)) and returns
+// that length, dispatching to the assembly implementation in
+// xor_amd64.s (mirroring crypto/aes/ctr_s390x.go's xorBytes).
+func xorBytes(dst, a, b []byte) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	if n == 0 {
+		return 0
+	}
+	xorBytesAsm(&dst[0], &a[0], &b[0], n)
+	return n
+}
@@ -0,0 +1,44 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !amd64 && !arm64
+
+package aesctr
+
+import "unsafe"
+
+const wordSize = unsafe.Sizeof(uintptr(0))
+
+// xorBytes sets dst[i] = a[i] ^ b[i] for i < min(len(a), len(b)) and returns
+// that length. It is the hot inner loop of XORKeyStream, so on platforms
+// without a dedicated assembly implementation it XORs a word at a time
+// (mirroring crypto/cipher/xor_generic.go) instead of byte by byte.
+func xorBytes(dst, a, b []byte) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	if n == 0 {
+		return 0
+	}
+
+	w := n / int(wordSize)
+	if w > 0 {
+		dstp := unsafe.Pointer(&dst[0])
+		ap := unsafe.Pointer(&a[0])
+		bp := unsafe.Pointer(&b[0])
+		for i := 0; i < w; i++ {
+			off := uintptr(i) * wordSize
+			dw := (*uintptr)(unsafe.Add(dstp, off))
+			aw := (*uintptr)(unsafe.Add(ap, off))
+			bw := (*uintptr)(unsafe.Add(bp, off))
+			*dw = *aw ^ *bw
+		}
+	}
+
+	for i := w * int(wordSize); i < n; i++ {
+		dst[i] = a[i] ^ b[i]
+	}
+	return n
+}
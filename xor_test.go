@@ -0,0 +1,67 @@
+package aesctr
+
+import (
+	"bytes"
+	"crypto/aes"
+	"testing"
+)
+
+func TestXorBytes(t *testing.T) {
+	a := make([]byte, 1031) // not a multiple of the word size
+	b := make([]byte, 1031)
+	for i := range a {
+		a[i] = byte(i)
+		b[i] = byte(i * 7)
+	}
+
+	dst := make([]byte, len(a))
+	n := xorBytes(dst, a, b)
+	if n != len(a) {
+		t.Fatalf("xorBytes returned %d, want %d", n, len(a))
+	}
+	for i := range dst {
+		if want := a[i] ^ b[i]; dst[i] != want {
+			t.Fatalf("dst[%d] = %#x, want %#x", i, dst[i], want)
+		}
+	}
+
+	// Shorter b should bound the result to len(b).
+	short := b[:100]
+	n = xorBytes(dst, a, short)
+	if n != len(short) {
+		t.Fatalf("xorBytes with short b returned %d, want %d", n, len(short))
+	}
+	if !bytes.Equal(dst[:n], func() []byte {
+		want := make([]byte, n)
+		for i := range want {
+			want[i] = a[i] ^ short[i]
+		}
+		return want
+	}()) {
+		t.Fatalf("xorBytes with short b produced wrong output")
+	}
+}
+
+// BenchmarkReadAt1MiB measures ReadAt throughput for a single large read,
+// which is dominated by XORKeyStream's call into xorBytes.
+func BenchmarkReadAt1MiB(b *testing.B) {
+	key := []byte("thisIsJustARandomStringOfChars=)")
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		b.Fatalf("Could not create cipher: %v", err)
+	}
+
+	plaintext := make([]byte, 1024*1024)
+	iv := make([]byte, block.BlockSize())
+
+	ciphertext := make([]byte, len(plaintext))
+	reader := bytes.NewReader(ciphertext)
+	decrypter := NewCTRReaderAt(block, iv, reader)
+
+	chunk := make([]byte, len(plaintext))
+	b.SetBytes(int64(len(chunk)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		decrypter.ReadAt(chunk, 0)
+	}
+}